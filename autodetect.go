@@ -0,0 +1,167 @@
+package gohlslib
+
+import (
+	mch264 "github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	mch265 "github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+
+	"github.com/bluenviron/gohlslib/pkg/codecs"
+)
+
+// videoParams is the set of parameters auto-detection extracts from a
+// video SPS/PPS, used both to tell whether the stream has been
+// reconfigured and to build the Track that is advertised for it.
+type videoParams struct {
+	isH265     bool
+	profileIdc uint8
+	levelIdc   uint8
+	width      int
+	height     int
+	frameRate  float64
+	vps        []byte
+	sps        []byte
+	pps        []byte
+}
+
+// detectVideoParams looks for a SPS and PPS (and, for H265, a VPS) in au
+// and, if found, returns the codec and parameters they advertise. ok is
+// false if au carries no SPS.
+func detectVideoParams(au [][]byte) (params videoParams, ok bool) {
+	for _, nalu := range au {
+		if len(nalu) == 0 {
+			continue
+		}
+
+		// H264: 1-byte header, NALU type in the low 5 bits. Type 7 is SPS,
+		// type 8 is PPS.
+		if (nalu[0]&0x1F) == 7 && len(nalu) > 1 {
+			var sps mch264.SPS
+			if err := sps.Unmarshal(nalu); err == nil {
+				p := videoParams{
+					profileIdc: sps.ProfileIdc,
+					levelIdc:   sps.LevelIdc,
+					width:      sps.Width(),
+					height:     sps.Height(),
+					frameRate:  sps.FPS(),
+					sps:        nalu,
+				}
+				for _, other := range au {
+					if len(other) > 0 && (other[0]&0x1F) == 8 {
+						p.pps = other
+						break
+					}
+				}
+				return p, true
+			}
+		}
+
+		// H265: 2-byte header, NALU type in bits 1-6 of the first byte.
+		// Type 32 is VPS, type 33 is SPS, type 34 is PPS.
+		if len(nalu) > 2 && ((nalu[0]>>1)&0x3F) == 33 {
+			var sps mch265.SPS
+			if err := sps.Unmarshal(nalu); err == nil {
+				p := videoParams{
+					isH265:     true,
+					profileIdc: sps.ProfileTierLevel.GeneralProfileIdc,
+					levelIdc:   sps.ProfileTierLevel.GeneralLevelIdc,
+					width:      sps.Width(),
+					height:     sps.Height(),
+					frameRate:  sps.FPS(),
+					sps:        nalu,
+				}
+				for _, other := range au {
+					if len(other) <= 1 {
+						continue
+					}
+					switch (other[0] >> 1) & 0x3F {
+					case 32:
+						p.vps = other
+					case 34:
+						p.pps = other
+					}
+				}
+				return p, true
+			}
+		}
+	}
+
+	return videoParams{}, false
+}
+
+// equal reports whether p and other describe the same stream
+// configuration. SPS/PPS/VPS are intentionally excluded: they may be
+// re-emitted byte-for-byte identical or with harmless padding differences
+// without the stream actually changing, so only the parameters that matter
+// for playback (codec, profile/level, resolution) are compared.
+func (p videoParams) equal(other videoParams) bool {
+	return p.isH265 == other.isH265 &&
+		p.profileIdc == other.profileIdc &&
+		p.levelIdc == other.levelIdc &&
+		p.width == other.width &&
+		p.height == other.height
+}
+
+// trackForVideoParams returns the Track that should be advertised for the
+// given auto-detected parameters, carrying the actual SPS/PPS so that
+// downstream container muxing (the fMP4 init segment) reflects the real
+// stream instead of a zero-field placeholder.
+func trackForVideoParams(p videoParams) *Track {
+	if p.isH265 {
+		return &Track{Codec: &codecs.H265{VPS: p.vps, SPS: p.sps, PPS: p.pps}}
+	}
+	return &Track{Codec: &codecs.H264{SPS: p.sps, PPS: p.pps}}
+}
+
+var adtsSampleRates = [...]int{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050,
+	16000, 12000, 11025, 8000, 7350,
+}
+
+// audioParams is the set of parameters auto-detection extracts from an
+// ADTS header.
+type audioParams struct {
+	objectType   int
+	sampleRate   int
+	channelCount int
+}
+
+// detectAudioParams parses the ADTS header possibly prefixing the first
+// access unit of a MPEG-4 Audio stream.
+func detectAudioParams(aus [][]byte) (params audioParams, ok bool) {
+	if len(aus) == 0 || len(aus[0]) < 7 {
+		return audioParams{}, false
+	}
+
+	b := aus[0]
+	if b[0] != 0xFF || (b[1]&0xF0) != 0xF0 {
+		return audioParams{}, false
+	}
+
+	freqIdx := (b[2] >> 2) & 0x0F
+	if int(freqIdx) >= len(adtsSampleRates) {
+		return audioParams{}, false
+	}
+
+	profile := (b[2] >> 6) & 0x03
+	chanConfig := ((b[2] & 0x01) << 2) | ((b[3] >> 6) & 0x03)
+
+	return audioParams{
+		objectType:   int(profile) + 1, // ADTS profile field = audioObjectType - 1
+		sampleRate:   adtsSampleRates[freqIdx],
+		channelCount: int(chanConfig),
+	}, true
+}
+
+// trackForAudioParams returns the Track that should be advertised for the
+// given auto-detected parameters.
+func trackForAudioParams(p audioParams) *Track {
+	return &Track{
+		Codec: &codecs.MPEG4Audio{
+			Config: mpeg4audio.Config{
+				Type:         mpeg4audio.ObjectType(p.objectType),
+				SampleRate:   p.sampleRate,
+				ChannelCount: p.channelCount,
+			},
+		},
+	}
+}
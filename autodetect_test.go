@@ -0,0 +1,52 @@
+package gohlslib
+
+import "testing"
+
+func TestDetectAudioParams(t *testing.T) {
+	// ADTS header: MPEG-4 AAC-LC, 44100 Hz, 2 channels.
+	adts := []byte{0xFF, 0xF1, 0x50, 0x80, 0x00, 0x1F, 0xFC}
+
+	params, ok := detectAudioParams([][]byte{adts})
+	if !ok {
+		t.Fatal("detectAudioParams() ok = false, want true")
+	}
+	if params.sampleRate != 44100 {
+		t.Errorf("sampleRate = %d, want 44100", params.sampleRate)
+	}
+	if params.channelCount != 2 {
+		t.Errorf("channelCount = %d, want 2", params.channelCount)
+	}
+	if params.objectType != 2 {
+		t.Errorf("objectType = %d, want 2 (AAC-LC)", params.objectType)
+	}
+}
+
+func TestDetectAudioParamsTooShort(t *testing.T) {
+	if _, ok := detectAudioParams([][]byte{{0xFF, 0xF1}}); ok {
+		t.Error("detectAudioParams() on truncated header ok = true, want false")
+	}
+	if _, ok := detectAudioParams(nil); ok {
+		t.Error("detectAudioParams(nil) ok = true, want false")
+	}
+}
+
+func TestDetectAudioParamsInvalidSyncWord(t *testing.T) {
+	notADTS := []byte{0x00, 0x00, 0x50, 0x80, 0x00, 0x1F, 0xFC}
+	if _, ok := detectAudioParams([][]byte{notADTS}); ok {
+		t.Error("detectAudioParams() on non-ADTS data ok = true, want false")
+	}
+}
+
+func TestVideoParamsEqual(t *testing.T) {
+	a := videoParams{profileIdc: 100, levelIdc: 31, width: 1280, height: 720, sps: []byte{1, 2, 3}}
+	b := videoParams{profileIdc: 100, levelIdc: 31, width: 1280, height: 720, sps: []byte{4, 5, 6}}
+
+	if !a.equal(b) {
+		t.Error("equal() = false for params differing only in raw SPS bytes, want true")
+	}
+
+	c := videoParams{profileIdc: 100, levelIdc: 31, width: 1920, height: 1080}
+	if a.equal(c) {
+		t.Error("equal() = true for params with different resolution, want false")
+	}
+}
@@ -0,0 +1,300 @@
+package gohlslib
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+
+	"github.com/bluenviron/gohlslib/pkg/codecs"
+	"github.com/bluenviron/gohlslib/pkg/playlist"
+)
+
+// ClientOnDataH26xFunc is the prototype of the callback passed to
+// ClientTrack.OnDataH26x.
+type ClientOnDataH26xFunc func(pts time.Duration, dts time.Duration, au [][]byte)
+
+// ClientOnDataMPEG4AudioFunc is the prototype of the callback passed to
+// ClientTrack.OnDataMPEG4Audio.
+type ClientOnDataMPEG4AudioFunc func(pts time.Duration, aus [][]byte)
+
+// ClientTrack is a track read by a Client.
+type ClientTrack struct {
+	// Track describes the codec of this track.
+	Track *Track
+
+	onDataH26x       ClientOnDataH26xFunc
+	onDataMPEG4Audio ClientOnDataMPEG4AudioFunc
+}
+
+// OnDataH26x sets the callback invoked when an H264/H265 access unit is
+// received on this track.
+func (t *ClientTrack) OnDataH26x(cb ClientOnDataH26xFunc) {
+	t.onDataH26x = cb
+}
+
+// OnDataMPEG4Audio sets the callback invoked when a group of MPEG-4 Audio
+// access units is received on this track.
+func (t *ClientTrack) OnDataMPEG4Audio(cb ClientOnDataMPEG4AudioFunc) {
+	t.onDataMPEG4Audio = cb
+}
+
+// Client reads a remote HLS stream, downloading the master playlist once,
+// then polling the media playlist for new segments and delivering decoded
+// access units through per-track callbacks.
+type Client struct {
+	// URI is the URL of the master or media playlist to read from.
+	URI string
+
+	// HTTPClient is used to download playlists and segments.
+	// Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mutex        sync.Mutex
+	tracks       []*ClientTrack
+	timeDec      *mpegts.TimeDecoder
+	mediaURI     string
+	lastMediaSeq uint64
+	closed       chan struct{}
+}
+
+// Start starts the client: it downloads the master playlist once, selects
+// the variant with the highest bandwidth, then starts polling the media
+// playlist for new segments until Close is called. Polling stops at the
+// first error downloading or parsing the media playlist or a segment.
+func (c *Client) Start() error {
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+	c.closed = make(chan struct{})
+
+	mediaURI, err := c.resolveMediaPlaylistURI()
+	if err != nil {
+		return err
+	}
+	c.mediaURI = mediaURI
+
+	go c.run()
+
+	return nil
+}
+
+// Close stops the client.
+func (c *Client) Close() {
+	close(c.closed)
+}
+
+// Tracks returns the tracks found in the stream. It is populated only
+// after the first segment has been downloaded and demuxed.
+func (c *Client) Tracks() []*ClientTrack {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.tracks
+}
+
+func (c *Client) resolveMediaPlaylistURI() (string, error) {
+	raw, err := c.get(c.URI)
+	if err != nil {
+		return "", err
+	}
+
+	master, _ := playlist.UnmarshalMaster(raw)
+	if master != nil && len(master.Variants) > 0 {
+		best := master.BestVariant()
+		return resolveURI(c.URI, best.URI), nil
+	}
+
+	// the URI was already a media playlist
+	return c.URI, nil
+}
+
+func (c *Client) run() {
+	for {
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+
+		err := c.reloadMediaPlaylist()
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-c.closed:
+			return
+		case <-time.After(1 * time.Second):
+		}
+	}
+}
+
+func (c *Client) reloadMediaPlaylist() error {
+	raw, err := c.get(c.mediaURI)
+	if err != nil {
+		return err
+	}
+
+	media, err := playlist.UnmarshalMedia(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, seg := range media.Segments {
+		if seg.Seq < c.lastMediaSeq {
+			continue
+		}
+		c.lastMediaSeq = seg.Seq + 1
+
+		err := c.downloadSegment(resolveURI(c.mediaURI, seg.URI))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) downloadSegment(uri string) error {
+	res, err := c.HTTPClient.Get(uri)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	r, err := mpegts.NewReader(mpegts.NewBufferedReader(res.Body))
+	if err != nil {
+		return fmt.Errorf("invalid MPEG-TS segment: %w", err)
+	}
+
+	c.setupTracks(r)
+
+	for {
+		err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// setupTracks wires up callbacks on r, the reader of the segment that was
+// just downloaded. It runs once per segment, since every segment gets its
+// own mpegts.Reader, but it must keep using the same ClientTrack (matched
+// by codec) and the same TimeDecoder across all of them: otherwise only
+// the first segment would ever be delivered, and the PTS/DTS of every
+// segment after it would restart from zero instead of continuing the
+// timeline established by the first one.
+func (c *Client) setupTracks(r *mpegts.Reader) {
+	for _, track := range r.Tracks() {
+		track := track
+
+		switch codec := track.Codec.(type) {
+		case *mpegts.CodecH264:
+			ct := c.clientTrackFor(func(t *Track) bool {
+				_, ok := t.Codec.(*codecs.H264)
+				return ok
+			}, func() *Track { return &Track{Codec: &codecs.H264{}} })
+
+			r.OnDataH26x(track, func(rawPTS int64, rawDTS int64, au [][]byte) error {
+				pts, dts := c.decodeTimes(rawPTS, rawDTS)
+				if ct.onDataH26x != nil {
+					ct.onDataH26x(pts, dts, au)
+				}
+				return nil
+			})
+
+		case *mpegts.CodecH265:
+			ct := c.clientTrackFor(func(t *Track) bool {
+				_, ok := t.Codec.(*codecs.H265)
+				return ok
+			}, func() *Track { return &Track{Codec: &codecs.H265{}} })
+
+			r.OnDataH26x(track, func(rawPTS int64, rawDTS int64, au [][]byte) error {
+				pts, dts := c.decodeTimes(rawPTS, rawDTS)
+				if ct.onDataH26x != nil {
+					ct.onDataH26x(pts, dts, au)
+				}
+				return nil
+			})
+
+		case *mpegts.CodecMPEG4Audio:
+			ct := c.clientTrackFor(func(t *Track) bool {
+				_, ok := t.Codec.(*codecs.MPEG4Audio)
+				return ok
+			}, func() *Track { return &Track{Codec: &codecs.MPEG4Audio{Config: codec.Config}} })
+
+			r.OnDataMPEG4Audio(track, func(rawPTS int64, aus [][]byte) error {
+				pts, _ := c.decodeTimes(rawPTS, rawPTS)
+				if ct.onDataMPEG4Audio != nil {
+					ct.onDataMPEG4Audio(pts, aus)
+				}
+				return nil
+			})
+		}
+	}
+}
+
+// clientTrackFor returns the ClientTrack matching the codec identified by
+// match, creating and registering one with newTrack if none has been
+// registered yet (e.g. on the first segment, or when audio only appears on
+// a later segment than video).
+func (c *Client) clientTrackFor(match func(*Track) bool, newTrack func() *Track) *ClientTrack {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, ct := range c.tracks {
+		if match(ct.Track) {
+			return ct
+		}
+	}
+
+	ct := &ClientTrack{Track: newTrack()}
+	c.tracks = append(c.tracks, ct)
+	return ct
+}
+
+// decodeTimes converts raw 90kHz MPEG-TS PTS/DTS values into time.Duration,
+// relative to the first value ever seen on this Client. Reusing the same
+// TimeDecoder for the whole session (instead of one per segment) is what
+// keeps the timeline continuous across segment boundaries.
+func (c *Client) decodeTimes(rawPTS int64, rawDTS int64) (time.Duration, time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.timeDec == nil {
+		c.timeDec = mpegts.NewTimeDecoder(rawPTS)
+	}
+
+	return c.timeDec.Decode(rawPTS), c.timeDec.Decode(rawDTS)
+}
+
+func (c *Client) get(uri string) ([]byte, error) {
+	res, err := c.HTTPClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return io.ReadAll(res.Body)
+}
+
+// resolveURI resolves a (possibly relative) segment/playlist URI against
+// the URI it was referenced from.
+func resolveURI(base string, ref string) string {
+	if strings.Contains(ref, "://") {
+		return ref
+	}
+
+	idx := strings.LastIndex(base, "/")
+	if idx == -1 {
+		return ref
+	}
+	return base[:idx+1] + ref
+}
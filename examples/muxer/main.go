@@ -5,7 +5,6 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
 	"strings"
 	"sync"
@@ -16,6 +15,7 @@ import (
 
 	"github.com/bluenviron/gohlslib"
 	"github.com/bluenviron/gohlslib/pkg/codecs"
+	"github.com/bluenviron/gohlslib/pkg/mpegtsudp"
 )
 
 // This example shows how to:
@@ -84,19 +84,13 @@ func main() {
 		fmt.Printf("Address: %s, Name: %s, Resolution: %s, Bandwidth: %s\n", info.Address, info.Name, info.Resolution, info.Bandwidth)
 		m3u8String += GenerateM3U8String(info.Bandwidth, info.Resolution)
 
-		pc, err := net.ListenPacket("udp", info.Address)
-		if err != nil {
-			panic(err)
-		}
-		defer pc.Close()
-
 		log.Println("Starting for ", info.Name, info.Address)
 		wg.Add(1)
 
-		go func(pc net.PacketConn, resolution string) {
+		go func(address string, resolution string) {
 			defer wg.Done()
-			setupMPEGTSReader(pc, resolution, *directory)
-		}(pc, info.Name)
+			setupMPEGTSReader(address, resolution, *directory)
+		}(info.Address, info.Name)
 	}
 
 	m3u8String += GenerateM3U8String("1000000", "1280x720")
@@ -104,7 +98,7 @@ func main() {
 	wg.Wait()
 }
 
-func setupMPEGTSReader(pc net.PacketConn, resolution string, directory string) {
+func setupMPEGTSReader(address string, resolution string, directory string) {
 	mux := &gohlslib.Muxer{
 		VideoTrack: &gohlslib.Track{
 			Codec: &codecs.H264{},
@@ -128,11 +122,13 @@ func setupMPEGTSReader(pc net.PacketConn, resolution string, directory string) {
 		panic(err)
 	}
 
-	// create a MPEG-TS reader
-	r, err := mpegts.NewReader(mpegts.NewBufferedReader(newPacketConnReader(pc)))
+	// listen for the UDP/multicast MPEG-TS stream and create a MPEG-TS reader
+	ing := &mpegtsudp.Ingester{Address: address}
+	r, err := ing.Start()
 	if err != nil {
 		panic(err)
 	}
+	defer ing.Close()
 
 	var timeDec *mpegts.TimeDecoder
 
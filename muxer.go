@@ -0,0 +1,478 @@
+// Package gohlslib contains a HLS muxer and client.
+package gohlslib
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSegmentDuration = 1 * time.Second
+	defaultPartDuration    = 200 * time.Millisecond
+	defaultSegmentCount    = 7
+)
+
+// Muxer generates HLS playlists and segments from incoming H26x and
+// MPEG-4 Audio streams.
+type Muxer struct {
+	// Variant is the output variant. Defaults to MuxerVariantMPEGTS.
+	Variant MuxerVariant
+
+	// VideoTrack is the video track to mux, if any. Can be left unset when
+	// AutoDetectTracks is true.
+	VideoTrack *Track
+
+	// AudioTrack is the audio track to mux, if any. Can be left unset when
+	// AutoDetectTracks is true.
+	AudioTrack *Track
+
+	// AutoDetectTracks makes the muxer determine VideoTrack and AudioTrack
+	// from the stream itself instead of trusting the caller's declaration:
+	// codec, profile/level, resolution and frame rate are extracted from
+	// the SPS/PPS of the first video access unit, and sample rate, channel
+	// count and audio object type from the ADTS header of the first audio
+	// access unit. Start can be called before these are known; the first
+	// call to WriteH26x/WriteMPEG4Audio completes the detection. If the
+	// stream is reconfigured mid-session, a new segment is started and
+	// marked with #EXT-X-DISCONTINUITY.
+	AutoDetectTracks bool
+
+	// Directory is the folder segments, parts, fMP4 initialization segments
+	// and the master playlist (index.m3u8) are written to. Media playlists
+	// are not written to it: they are generated on request by Handle.
+	// Segments and parts are kept in memory and served through Handle
+	// either way; if Directory is empty, nothing is written to disk.
+	Directory string
+
+	// SegmentCount is the maximum number of segments kept in the media
+	// playlist. Defaults to 7. Ignored when PlaylistType is not
+	// MuxerPlaylistTypeLive.
+	SegmentCount int
+
+	// PlaylistType selects the retention policy of the media playlist.
+	// Defaults to MuxerPlaylistTypeLive.
+	PlaylistType MuxerPlaylistType
+
+	// MaxSegmentAge, in LIVE mode, evicts a segment once it is older than
+	// this duration, in addition to the SegmentCount limit. Zero disables
+	// the age-based limit.
+	MaxSegmentAge time.Duration
+
+	// MaxDiskUsage, in LIVE mode, evicts the oldest segments once their
+	// combined size exceeds this many bytes, in addition to the
+	// SegmentCount limit. Zero disables the size-based limit.
+	MaxDiskUsage int64
+
+	// OnSegmentEvicted, if set, is called with the path (or name, if
+	// Directory is empty), content and sequence number of every segment
+	// about to leave the LIVE retention window, before it is deleted, so
+	// that callers can archive it (e.g. upload it to object storage)
+	// first, even when Directory is empty and nothing is left on disk to
+	// read it back from. It is called without any internal lock held, so
+	// it may safely take time (e.g. a network upload) or call back into
+	// the Muxer.
+	OnSegmentEvicted func(path string, data []byte, seq uint64)
+
+	// SegmentDuration is the target duration of a full segment.
+	// Defaults to 1s.
+	SegmentDuration time.Duration
+
+	// PartDuration is the target duration of a CMAF part, used when
+	// Variant is MuxerVariantLowLatency. Defaults to 200ms.
+	PartDuration time.Duration
+
+	// Prefix is prepended to the name of the media playlist and its segments.
+	// Unused when Renditions is set.
+	Prefix string
+
+	// Renditions, when non-empty, turns the Muxer into the manager of a
+	// whole ABR ladder: each Rendition gets its own media playlist and
+	// segments, and the master playlist advertising all of them is
+	// generated and kept up to date automatically.
+	Renditions []*Rendition
+
+	// AlternateAudio lists audio-only renditions advertised through
+	// #EXT-X-MEDIA in the master playlist. Only used together with Renditions.
+	AlternateAudio []*AlternateAudio
+
+	mutex         sync.Mutex
+	segmenter     *muxerSegmenter
+	manifest      []byte
+	started       bool
+	detectedVideo *videoParams
+	detectedAudio *audioParams
+}
+
+// Start initializes the muxer. It can be called before VideoTrack/AudioTrack
+// are known when AutoDetectTracks is set; detection then completes on the
+// first WriteH26x/WriteMPEG4Audio call (which also calls Start if it wasn't
+// called explicitly).
+func (m *Muxer) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.started {
+		return nil
+	}
+	return m.startLocked()
+}
+
+// startLocked performs the actual initialization. Callers must hold m.mutex.
+func (m *Muxer) startLocked() error {
+	if m.SegmentDuration == 0 {
+		m.SegmentDuration = defaultSegmentDuration
+	}
+	if m.PartDuration == 0 {
+		m.PartDuration = defaultPartDuration
+	}
+	if m.SegmentCount == 0 {
+		m.SegmentCount = defaultSegmentCount
+	}
+	if m.Prefix == "" {
+		m.Prefix = "stream"
+	}
+
+	if m.Directory != "" {
+		err := os.MkdirAll(m.Directory, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(m.Renditions) > 0 {
+		for _, re := range m.Renditions {
+			re.start(m)
+		}
+		for _, aa := range m.AlternateAudio {
+			aa.start(m)
+		}
+		if err := m.setManifestLocked(m.buildMasterManifest()); err != nil {
+			return err
+		}
+	} else {
+		m.segmenter = newMuxerSegmenter(m.segmenterConfig())
+	}
+
+	m.started = true
+
+	return nil
+}
+
+// regenerateMasterManifest rebuilds and stores the master playlist. It is
+// called every time a Rendition detects its video parameters for the first
+// time, so that the playlist's CODECS/RESOLUTION attributes catch up with
+// reality instead of staying empty.
+func (m *Muxer) regenerateMasterManifest() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_ = m.setManifestLocked(m.buildMasterManifest())
+}
+
+// buildMasterManifest renders the master playlist from the current
+// Renditions and AlternateAudio, deriving CODECS and RESOLUTION from the
+// actual stream parameters detected so far instead of from user input.
+// Callers must hold m.mutex.
+func (m *Muxer) buildMasterManifest() string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:9\n#EXT-X-INDEPENDENT-SEGMENTS\n\n")
+
+	for _, aa := range m.AlternateAudio {
+		b.WriteString(fmt.Sprintf(
+			"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=%q,NAME=%q,AUTOSELECT=YES", aa.GroupID, aa.Name))
+		if aa.Language != "" {
+			b.WriteString(fmt.Sprintf(",LANGUAGE=%q", aa.Language))
+		}
+		if aa.Default {
+			b.WriteString(",DEFAULT=YES")
+		}
+		b.WriteString(fmt.Sprintf(",URI=%q\n", aa.playlistName()))
+	}
+
+	for _, re := range m.Renditions {
+		re.mutex.Lock()
+		codecsTag := re.codecs
+		resolution := re.resolution
+		re.mutex.Unlock()
+
+		b.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d", re.Bandwidth, re.Bandwidth))
+		if codecsTag != "" {
+			b.WriteString(fmt.Sprintf(",CODECS=%q", codecsTag))
+		}
+		if resolution != "" {
+			b.WriteString(fmt.Sprintf(",RESOLUTION=%s", resolution))
+		}
+		if re.FrameRate != 0 {
+			b.WriteString(fmt.Sprintf(",FRAME-RATE=%.3f", re.FrameRate))
+		}
+		if re.AudioGroup != "" {
+			b.WriteString(fmt.Sprintf(",AUDIO=%q", re.AudioGroup))
+		}
+		b.WriteString("\n")
+		b.WriteString(re.playlistName() + "\n\n")
+	}
+
+	return b.String()
+}
+
+// segmenterConfig returns the settings shared by every segmenter created by
+// this Muxer, whether it belongs to the legacy single-rendition output or
+// to one rendition of an ABR ladder.
+func (m *Muxer) segmenterConfig() muxerSegmenterConfig {
+	return muxerSegmenterConfig{
+		variant:          m.Variant,
+		videoTrack:       m.VideoTrack,
+		audioTrack:       m.AudioTrack,
+		segmentDuration:  m.SegmentDuration,
+		partDuration:     m.PartDuration,
+		segmentCount:     m.SegmentCount,
+		directory:        m.Directory,
+		prefix:           m.Prefix,
+		playlistType:     m.PlaylistType,
+		maxSegmentAge:    m.MaxSegmentAge,
+		maxDiskUsage:     m.MaxDiskUsage,
+		onSegmentEvicted: m.OnSegmentEvicted,
+	}
+}
+
+// Close closes a Muxer, releasing every resource associated with it.
+func (m *Muxer) Close() {
+	// close may invoke OnSegmentEvicted, which must run without m.mutex held.
+	m.mutex.Lock()
+	segs := m.segmenters()
+	m.mutex.Unlock()
+
+	for _, seg := range segs {
+		// With AutoDetectTracks, Start (and therefore segmenter creation) is
+		// deferred until the first WriteH26x/WriteMPEG4Audio call. A Muxer
+		// that is closed without ever receiving data has no segmenter yet.
+		if seg == nil {
+			continue
+		}
+		seg.close()
+	}
+}
+
+// WriteH26x writes an H264 or H265 access unit.
+func (m *Muxer) WriteH26x(ntp time.Time, pts time.Duration, au [][]byte) error {
+	if err := m.ensureStarted(); err != nil {
+		return err
+	}
+
+	if m.AutoDetectTracks {
+		m.detectVideo(pts, au)
+	}
+
+	return m.segmenter.writeH26x(ntp, pts, au)
+}
+
+// WriteMPEG4Audio writes a group of MPEG-4 Audio access units.
+func (m *Muxer) WriteMPEG4Audio(ntp time.Time, pts time.Duration, aus [][]byte) error {
+	if err := m.ensureStarted(); err != nil {
+		return err
+	}
+
+	if m.AutoDetectTracks {
+		m.detectAudio(pts, aus)
+	}
+
+	return m.segmenter.writeMPEG4Audio(ntp, pts, aus)
+}
+
+// ensureStarted lazily runs Start if the caller relies on AutoDetectTracks
+// and hasn't called Start yet.
+func (m *Muxer) ensureStarted() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.started {
+		return nil
+	}
+	return m.startLocked()
+}
+
+// detectVideo completes VideoTrack detection on the first call, then
+// watches for mid-stream reconfiguration.
+func (m *Muxer) detectVideo(pts time.Duration, au [][]byte) {
+	params, ok := detectVideoParams(au)
+	if !ok {
+		return
+	}
+
+	m.mutex.Lock()
+
+	if m.detectedVideo == nil {
+		m.detectedVideo = &params
+		m.VideoTrack = trackForVideoParams(params)
+		m.segmenter.setTracks(m.VideoTrack, m.AudioTrack)
+		m.mutex.Unlock()
+		return
+	}
+
+	reconfigured := !m.detectedVideo.equal(params)
+	if reconfigured {
+		m.detectedVideo = &params
+		m.VideoTrack = trackForVideoParams(params)
+		m.segmenter.setTracks(m.VideoTrack, m.AudioTrack)
+	}
+
+	m.mutex.Unlock()
+
+	// forceNewSegment may invoke OnSegmentEvicted, which must run without
+	// m.mutex held.
+	if reconfigured {
+		m.segmenter.forceNewSegment(pts, true)
+	}
+}
+
+// detectAudio completes AudioTrack detection on the first call, then
+// watches for mid-stream reconfiguration.
+func (m *Muxer) detectAudio(pts time.Duration, aus [][]byte) {
+	params, ok := detectAudioParams(aus)
+	if !ok {
+		return
+	}
+
+	m.mutex.Lock()
+
+	if m.detectedAudio == nil {
+		m.detectedAudio = &params
+		m.AudioTrack = trackForAudioParams(params)
+		m.segmenter.setTracks(m.VideoTrack, m.AudioTrack)
+		m.mutex.Unlock()
+		return
+	}
+
+	reconfigured := *m.detectedAudio != params
+	if reconfigured {
+		m.detectedAudio = &params
+		m.AudioTrack = trackForAudioParams(params)
+		m.segmenter.setTracks(m.VideoTrack, m.AudioTrack)
+	}
+
+	m.mutex.Unlock()
+
+	// forceNewSegment may invoke OnSegmentEvicted, which must run without
+	// m.mutex held.
+	if reconfigured {
+		m.segmenter.forceNewSegment(pts, true)
+	}
+}
+
+// GenerateMainManifest stores the master playlist that is served by Handle
+// and, when Directory is set, written to disk as index.m3u8.
+func (m *Muxer) GenerateMainManifest(manifest string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.setManifestLocked(manifest)
+}
+
+// setManifestLocked stores the master playlist and, when Directory is set,
+// writes it to disk as index.m3u8. Callers must hold m.mutex.
+func (m *Muxer) setManifestLocked(manifest string) error {
+	m.manifest = []byte(manifest)
+
+	if m.Directory != "" {
+		return os.WriteFile(filepath.Join(m.Directory, "index.m3u8"), m.manifest, 0o644)
+	}
+	return nil
+}
+
+// Handle serves the master playlist, media playlist, segments and parts of
+// a muxer that doesn't write to Directory. It implements the blocking
+// playlist reload mechanism used by Low-Latency HLS (the _HLS_msn and
+// _HLS_part query parameters) and serves byte ranges of partial segments.
+func (m *Muxer) Handle(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+
+	switch {
+	case name == "" || name == "index.m3u8":
+		m.mutex.Lock()
+		manifest := m.manifest
+		m.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Write(manifest)
+
+	case strings.HasSuffix(name, ".m3u8"):
+		seg := m.segmenterByPlaylistName(name)
+		if seg == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		msn, part, blocking := parseBlockingReloadParams(r.URL.Query())
+		if blocking {
+			seg.waitUntilAvailable(r.Context(), msn, part)
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Write([]byte(seg.generateMediaPlaylist()))
+
+	default:
+		for _, seg := range m.segmenters() {
+			if data, ok := seg.segmentOrPartByName(name); ok {
+				http.ServeContent(w, r, name, time.Time{}, newBytesReadSeeker(data))
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}
+}
+
+// segmenters returns every segmenter managed by this Muxer: the single
+// legacy one, or one per Rendition/AlternateAudio when an ABR ladder is in use.
+func (m *Muxer) segmenters() []*muxerSegmenter {
+	if len(m.Renditions) == 0 {
+		return []*muxerSegmenter{m.segmenter}
+	}
+
+	segs := make([]*muxerSegmenter, 0, len(m.Renditions)+len(m.AlternateAudio))
+	for _, re := range m.Renditions {
+		segs = append(segs, re.segmenter)
+	}
+	for _, aa := range m.AlternateAudio {
+		segs = append(segs, aa.segmenter)
+	}
+	return segs
+}
+
+// segmenterByPlaylistName returns the segmenter whose media playlist is
+// named name, or nil if none matches.
+func (m *Muxer) segmenterByPlaylistName(name string) *muxerSegmenter {
+	if len(m.Renditions) == 0 {
+		return m.segmenter
+	}
+
+	for _, re := range m.Renditions {
+		if re.playlistName() == name {
+			return re.segmenter
+		}
+	}
+	for _, aa := range m.AlternateAudio {
+		if aa.playlistName() == name {
+			return aa.segmenter
+		}
+	}
+	return nil
+}
+
+// parseBlockingReloadParams extracts the _HLS_msn and _HLS_part query
+// parameters used to request a blocking playlist reload. part is -1 when
+// _HLS_part is absent.
+func parseBlockingReloadParams(q map[string][]string) (msn int, part int, blocking bool) {
+	part = -1
+	if v, ok := q["_HLS_msn"]; ok && len(v) > 0 {
+		msn, _ = strconv.Atoi(v[0])
+		blocking = true
+	}
+	if v, ok := q["_HLS_part"]; ok && len(v) > 0 {
+		part, _ = strconv.Atoi(v[0])
+	}
+	return
+}
@@ -0,0 +1,321 @@
+package gohlslib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+	"github.com/orcaman/writerseeker"
+
+	"github.com/bluenviron/gohlslib/pkg/codecs"
+)
+
+const (
+	mpegtsTimeScale       = 90000
+	fmp4VideoTimeScale    = 90000
+	defaultAudioTimeScale = 48000
+	samplesPerAACFrame    = 1024
+)
+
+// segmentMuxer packetizes access units into the bytes of a single segment
+// or CMAF part, in the container format selected by MuxerVariant. A fresh
+// instance is created for every segment (MuxerVariantMPEGTS/MuxerVariantFMP4)
+// or part (MuxerVariantLowLatency), so that each one is a self-contained,
+// independently decodable unit.
+type segmentMuxer interface {
+	writeH26x(pts time.Duration, randomAccess bool, au [][]byte) error
+	writeMPEG4Audio(pts time.Duration, aus [][]byte) error
+
+	// bytes renders every sample written so far into the container's byte
+	// representation. end is the PTS the segment/part is being closed at,
+	// used to compute the duration of the last sample.
+	bytes(end time.Duration) ([]byte, error)
+}
+
+func newSegmentMuxer(variant MuxerVariant, video, audio *Track) segmentMuxer {
+	if variant == MuxerVariantMPEGTS {
+		return newMPEGTSSegmentMuxer(video, audio)
+	}
+	return newFMP4SegmentMuxer(video, audio)
+}
+
+// isRandomAccessH26x reports whether au carries a random access point
+// (an IDR frame, or the parameter sets that precede one), used both for
+// the MPEG-TS PES random_access_indicator and to mark fMP4 samples as sync
+// samples.
+func isRandomAccessH26x(codec codecs.Codec, au [][]byte) bool {
+	_, isH265 := codec.(*codecs.H265)
+
+	for _, nalu := range au {
+		if len(nalu) == 0 {
+			continue
+		}
+
+		if isH265 {
+			typ := (nalu[0] >> 1) & 0x3F
+			if typ == 19 || typ == 20 || typ == 32 { // IDR_W_RADL, IDR_N_LP, VPS
+				return true
+			}
+			continue
+		}
+
+		typ := nalu[0] & 0x1F
+		if typ == 5 || typ == 7 { // IDR, SPS
+			return true
+		}
+	}
+
+	return false
+}
+
+// avccMarshal converts a slice of Annex-B-style NALUs (no start codes) into
+// the AVCC/HVCC representation fMP4 requires: each NALU prefixed by its
+// big-endian 4-byte length.
+func avccMarshal(au [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, nalu := range au {
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(nalu)))
+		buf.Write(lenBuf[:])
+		buf.Write(nalu)
+	}
+	return buf.Bytes()
+}
+
+func durationToTimescale(d time.Duration, timescale int) int64 {
+	return int64(d * time.Duration(timescale) / time.Second)
+}
+
+func audioSampleRate(t *Track) int {
+	if t == nil {
+		return defaultAudioTimeScale
+	}
+	if a, ok := t.Codec.(*codecs.MPEG4Audio); ok && a.Config.SampleRate > 0 {
+		return a.Config.SampleRate
+	}
+	return defaultAudioTimeScale
+}
+
+// audioSampleDuration returns the duration of a single MPEG-4 Audio access
+// unit. AAC frames carry a fixed number of samples (1024), so the duration
+// can be derived from the sample rate alone, without needing the PTS of the
+// following sample.
+func audioSampleDuration(t *Track) time.Duration {
+	return time.Duration(samplesPerAACFrame) * time.Second / time.Duration(audioSampleRate(t))
+}
+
+// mpegtsSegmentMuxer wraps mediacommon's MPEG-TS writer, the same package
+// Client uses to read segments, so that every .ts file this package
+// produces carries a real PAT/PMT and PES-framed elementary streams instead
+// of raw, un-packetized access units.
+type mpegtsSegmentMuxer struct {
+	buf        bytes.Buffer
+	w          *mpegts.Writer
+	videoTrack *mpegts.Track
+	audioTrack *mpegts.Track
+}
+
+func newMPEGTSSegmentMuxer(video, audio *Track) *mpegtsSegmentMuxer {
+	m := &mpegtsSegmentMuxer{}
+
+	var tracks []*mpegts.Track
+	if video != nil {
+		m.videoTrack = &mpegts.Track{Codec: mpegtsCodecForVideo(video.Codec)}
+		tracks = append(tracks, m.videoTrack)
+	}
+	if audio != nil {
+		m.audioTrack = &mpegts.Track{Codec: mpegtsCodecForAudio(audio.Codec)}
+		tracks = append(tracks, m.audioTrack)
+	}
+
+	m.w = mpegts.NewWriter(&m.buf, tracks)
+
+	return m
+}
+
+func (m *mpegtsSegmentMuxer) writeH26x(pts time.Duration, randomAccess bool, au [][]byte) error {
+	if m.videoTrack == nil {
+		return nil
+	}
+	ts := durationToTimescale(pts, mpegtsTimeScale)
+	return m.w.WriteH26x(m.videoTrack, ts, ts, randomAccess, au)
+}
+
+func (m *mpegtsSegmentMuxer) writeMPEG4Audio(pts time.Duration, aus [][]byte) error {
+	if m.audioTrack == nil {
+		return nil
+	}
+	return m.w.WriteMPEG4Audio(m.audioTrack, durationToTimescale(pts, mpegtsTimeScale), aus)
+}
+
+func (m *mpegtsSegmentMuxer) bytes(time.Duration) ([]byte, error) {
+	return m.buf.Bytes(), nil
+}
+
+func mpegtsCodecForVideo(c codecs.Codec) mpegts.Codec {
+	if _, ok := c.(*codecs.H265); ok {
+		return &mpegts.CodecH265{}
+	}
+	return &mpegts.CodecH264{}
+}
+
+func mpegtsCodecForAudio(c codecs.Codec) mpegts.Codec {
+	if a, ok := c.(*codecs.MPEG4Audio); ok {
+		return &mpegts.CodecMPEG4Audio{Config: a.Config}
+	}
+	return &mpegts.CodecMPEG4Audio{}
+}
+
+// fmp4VideoSample and fmp4AudioSample hold a single access unit until the
+// segment/part is closed. A video sample's Duration can only be computed
+// once the PTS of the following sample (or the end of the segment/part) is
+// known; an audio sample's Duration is always samplesPerAACFrame.
+type fmp4VideoSample struct {
+	pts          time.Duration
+	randomAccess bool
+	payload      []byte
+}
+
+type fmp4AudioSample struct {
+	pts     time.Duration
+	payload []byte
+}
+
+// fmp4SegmentMuxer accumulates samples and, once the segment/part is
+// closed, marshals them into a single CMAF fragment (one moof/mdat pair
+// per track) using mediacommon's ISOBMFF writer.
+type fmp4SegmentMuxer struct {
+	videoTrack   *Track
+	audioTrack   *Track
+	videoSamples []fmp4VideoSample
+	audioSamples []fmp4AudioSample
+}
+
+func newFMP4SegmentMuxer(video, audio *Track) *fmp4SegmentMuxer {
+	return &fmp4SegmentMuxer{videoTrack: video, audioTrack: audio}
+}
+
+func (m *fmp4SegmentMuxer) writeH26x(pts time.Duration, randomAccess bool, au [][]byte) error {
+	if m.videoTrack == nil {
+		return nil
+	}
+	m.videoSamples = append(m.videoSamples, fmp4VideoSample{
+		pts:          pts,
+		randomAccess: randomAccess,
+		payload:      avccMarshal(au),
+	})
+	return nil
+}
+
+func (m *fmp4SegmentMuxer) writeMPEG4Audio(pts time.Duration, aus [][]byte) error {
+	if m.audioTrack == nil {
+		return nil
+	}
+	for _, au := range aus {
+		m.audioSamples = append(m.audioSamples, fmp4AudioSample{pts: pts, payload: au})
+		pts += audioSampleDuration(m.audioTrack)
+	}
+	return nil
+}
+
+func (m *fmp4SegmentMuxer) bytes(end time.Duration) ([]byte, error) {
+	part := &fmp4.Part{}
+
+	if len(m.videoSamples) > 0 {
+		pt := &fmp4.PartTrack{
+			ID:       1,
+			BaseTime: uint64(durationToTimescale(m.videoSamples[0].pts, fmp4VideoTimeScale)),
+		}
+
+		for i, s := range m.videoSamples {
+			next := end
+			if i+1 < len(m.videoSamples) {
+				next = m.videoSamples[i+1].pts
+			}
+			pt.Samples = append(pt.Samples, &fmp4.PartSample{
+				Duration:        uint32(durationToTimescale(next-s.pts, fmp4VideoTimeScale)),
+				IsNonSyncSample: !s.randomAccess,
+				Payload:         s.payload,
+			})
+		}
+
+		part.Tracks = append(part.Tracks, pt)
+	}
+
+	if len(m.audioSamples) > 0 {
+		rate := audioSampleRate(m.audioTrack)
+		pt := &fmp4.PartTrack{
+			ID:       2,
+			BaseTime: uint64(durationToTimescale(m.audioSamples[0].pts, rate)),
+		}
+
+		dur := uint32(samplesPerAACFrame)
+		for _, s := range m.audioSamples {
+			pt.Samples = append(pt.Samples, &fmp4.PartSample{
+				Duration: dur,
+				Payload:  s.payload,
+			})
+		}
+
+		part.Tracks = append(part.Tracks, pt)
+	}
+
+	// fmp4.Part.Marshal requires an io.WriteSeeker (it seeks back to patch
+	// box sizes once their contents are known), which *bytes.Buffer doesn't
+	// implement.
+	var ws writerseeker.WriterSeeker
+	if err := part.Marshal(&ws); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(ws.Reader())
+}
+
+// buildFMP4Init renders the fMP4 initialization segment (ftyp/moov) that a
+// MuxerVariantFMP4 or MuxerVariantLowLatency media playlist references
+// through #EXT-X-MAP, built from the actual SPS/PPS/VPS and audio config
+// carried by the tracks instead of placeholder values.
+func buildFMP4Init(video, audio *Track) ([]byte, error) {
+	init := &fmp4.Init{}
+
+	if video != nil {
+		init.Tracks = append(init.Tracks, &fmp4.InitTrack{
+			ID:        1,
+			TimeScale: fmp4VideoTimeScale,
+			Codec:     fmp4CodecForVideo(video.Codec),
+		})
+	}
+	if audio != nil {
+		init.Tracks = append(init.Tracks, &fmp4.InitTrack{
+			ID:        2,
+			TimeScale: uint32(audioSampleRate(audio)),
+			Codec:     fmp4CodecForAudio(audio.Codec),
+		})
+	}
+
+	var ws writerseeker.WriterSeeker
+	if err := init.Marshal(&ws); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(ws.Reader())
+}
+
+func fmp4CodecForVideo(c codecs.Codec) fmp4.Codec {
+	switch t := c.(type) {
+	case *codecs.H265:
+		return &fmp4.CodecH265{VPS: t.VPS, SPS: t.SPS, PPS: t.PPS}
+	case *codecs.H264:
+		return &fmp4.CodecH264{SPS: t.SPS, PPS: t.PPS}
+	default:
+		return &fmp4.CodecH264{}
+	}
+}
+
+func fmp4CodecForAudio(c codecs.Codec) fmp4.Codec {
+	if a, ok := c.(*codecs.MPEG4Audio); ok {
+		return &fmp4.CodecMPEG4Audio{Config: a.Config}
+	}
+	return &fmp4.CodecMPEG4Audio{}
+}
@@ -0,0 +1,521 @@
+package gohlslib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gohlslib/pkg/codecs"
+	"github.com/bluenviron/gohlslib/pkg/playlist"
+)
+
+// muxerPart is a single CMAF partial segment, used in Low-Latency HLS.
+type muxerPart struct {
+	name        string
+	index       int
+	duration    time.Duration
+	independent bool
+	data        []byte
+}
+
+// muxerSegment is a full media segment, optionally made of several parts.
+type muxerSegment struct {
+	name          string
+	seq           uint64
+	start         time.Duration
+	duration      time.Duration
+	parts         []*muxerPart
+	data          []byte
+	discontinuity bool
+}
+
+// muxerSegmenterConfig holds the settings a muxerSegmenter needs. It is
+// populated either from the Muxer itself (single-rendition use) or from a
+// Rendition / AlternateAudio (ABR ladder use), so that every output of a
+// ladder shares the Muxer's global settings (Variant, durations, Directory)
+// while keeping its own Prefix and tracks.
+type muxerSegmenterConfig struct {
+	variant    MuxerVariant
+	videoTrack *Track
+	audioTrack *Track
+
+	segmentDuration time.Duration
+	partDuration    time.Duration
+	segmentCount    int
+	directory       string
+	prefix          string
+
+	playlistType     MuxerPlaylistType
+	maxSegmentAge    time.Duration
+	maxDiskUsage     int64
+	onSegmentEvicted func(path string, data []byte, seq uint64)
+}
+
+// muxerSegmenter accumulates access units into parts and segments, muxes
+// them into real container files (MPEG-TS or fMP4, depending on Variant),
+// keeps the rolling window advertised in the media playlist, and wakes up
+// blocking playlist reloads (EXT-X-SERVER-CONTROL / _HLS_msn,_HLS_part) as
+// soon as the segment or part they are waiting for becomes available.
+type muxerSegmenter struct {
+	cfg muxerSegmenterConfig
+
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	closed     bool
+	started    bool
+	nextSeq    uint64
+	segments   []*muxerSegment
+	curSegment *muxerSegment
+
+	videoTrack *Track
+	audioTrack *Track
+
+	initSegment []byte
+	initName    string
+
+	curSegmentMux     segmentMuxer
+	curSegmentHasData bool
+	curPartIndex      int
+	curPartStart      time.Duration
+	curPartMux        segmentMuxer
+	curPartHasData    bool
+	segStart          time.Duration
+	totalBytes        int64
+	lastPTS           time.Duration
+
+	pendingDiscontinuity bool
+}
+
+func newMuxerSegmenter(cfg muxerSegmenterConfig) *muxerSegmenter {
+	s := &muxerSegmenter{cfg: cfg}
+	s.cond = sync.NewCond(&s.mutex)
+	s.setTracksLocked(cfg.videoTrack, cfg.audioTrack)
+	return s
+}
+
+// setTracks (re)configures the segmenter's video/audio tracks and, for
+// MuxerVariantFMP4/MuxerVariantLowLatency, rebuilds the fMP4 init segment
+// so it reflects the real SPS/PPS/VPS just detected. Used by
+// AutoDetectTracks, whose tracks are unknown (or incomplete) until the
+// first access unit of each kind has been seen.
+func (s *muxerSegmenter) setTracks(video, audio *Track) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.setTracksLocked(video, audio)
+}
+
+func (s *muxerSegmenter) setTracksLocked(video, audio *Track) {
+	s.videoTrack = video
+	s.audioTrack = audio
+
+	if s.cfg.variant == MuxerVariantMPEGTS || (video == nil && audio == nil) {
+		return
+	}
+
+	data, err := buildFMP4Init(video, audio)
+	if err != nil {
+		return
+	}
+
+	s.initSegment = data
+	s.initName = s.cfg.prefix + "_init.mp4"
+
+	if s.cfg.directory != "" {
+		_ = os.WriteFile(filepath.Join(s.cfg.directory, s.initName), s.initSegment, 0o644)
+	}
+}
+
+// close finalizes any segment/part still being filled (so that a VOD
+// playlist includes every sample written before Close) and marks the
+// segmenter as closed, waking up any blocking playlist reload.
+func (s *muxerSegmenter) close() {
+	s.mutex.Lock()
+
+	var evicted []*muxerSegment
+	if s.started && s.curSegment != nil && s.curSegmentHasData {
+		if s.cfg.variant == MuxerVariantLowLatency && s.curPartHasData {
+			s.finishPart(s.lastPTS)
+		}
+		evicted = s.finishSegment(s.lastPTS)
+	}
+
+	s.closed = true
+	s.cond.Broadcast()
+
+	s.mutex.Unlock()
+
+	s.notifyEvicted(evicted)
+}
+
+func (s *muxerSegmenter) writeH26x(ntp time.Time, pts time.Duration, au [][]byte) error {
+	s.mutex.Lock()
+
+	var codec codecs.Codec
+	if s.videoTrack != nil {
+		codec = s.videoTrack.Codec
+	}
+	randomAccess := isRandomAccessH26x(codec, au)
+
+	evicted, err := s.writeSample(pts, func(mux segmentMuxer) error {
+		return mux.writeH26x(pts, randomAccess, au)
+	})
+
+	s.mutex.Unlock()
+
+	s.notifyEvicted(evicted)
+	return err
+}
+
+func (s *muxerSegmenter) writeMPEG4Audio(ntp time.Time, pts time.Duration, aus [][]byte) error {
+	s.mutex.Lock()
+
+	evicted, err := s.writeSample(pts, func(mux segmentMuxer) error {
+		return mux.writeMPEG4Audio(pts, aus)
+	})
+
+	s.mutex.Unlock()
+
+	s.notifyEvicted(evicted)
+	return err
+}
+
+// forceNewSegment closes the part/segment currently being filled (if any)
+// and starts a new one, optionally marking it with #EXT-X-DISCONTINUITY.
+// It is used when the input stream's parameters change mid-stream, e.g.
+// when AutoDetectTracks notices a codec reconfiguration.
+func (s *muxerSegmenter) forceNewSegment(pts time.Duration, discontinuity bool) {
+	s.mutex.Lock()
+
+	if !s.started {
+		s.mutex.Unlock()
+		return
+	}
+
+	if s.cfg.variant == MuxerVariantLowLatency && s.curPartHasData {
+		s.finishPart(pts)
+	}
+	evicted := s.finishSegment(pts)
+
+	if discontinuity {
+		s.pendingDiscontinuity = true
+	}
+
+	s.startSegment(pts)
+	if s.cfg.variant == MuxerVariantLowLatency {
+		s.startPart(pts)
+	}
+
+	s.cond.Broadcast()
+
+	s.mutex.Unlock()
+
+	s.notifyEvicted(evicted)
+}
+
+// notifyEvicted invokes cfg.onSegmentEvicted for every segment in evicted
+// and then deletes the segment's file, if any, so the callback can still
+// read it from disk. It must be called without s.mutex held:
+// onSegmentEvicted is user code (e.g. an upload to object storage) that may
+// take time or call back into the segmenter, and holding the lock across it
+// would stall every other write/read for as long as it runs.
+func (s *muxerSegmenter) notifyEvicted(evicted []*muxerSegment) {
+	for _, seg := range evicted {
+		path := seg.name
+		if s.cfg.directory != "" {
+			path = filepath.Join(s.cfg.directory, seg.name)
+		}
+		if s.cfg.onSegmentEvicted != nil {
+			s.cfg.onSegmentEvicted(path, seg.data, seg.seq)
+		}
+		if s.cfg.directory != "" {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+// writeSample feeds one sample (one H26x access unit, or one group of
+// MPEG-4 Audio access units) into the part or segment currently being
+// filled, via write, and rotates to a new part/segment once the
+// configured duration has elapsed. Callers must hold s.mutex. It returns
+// any segments evicted as a result, which the caller must report via
+// notifyEvicted after releasing the lock.
+func (s *muxerSegmenter) writeSample(pts time.Duration, write func(segmentMuxer) error) ([]*muxerSegment, error) {
+	s.lastPTS = pts
+
+	if !s.started {
+		s.started = true
+		s.startSegment(pts)
+		if s.cfg.variant == MuxerVariantLowLatency {
+			s.startPart(pts)
+		}
+	}
+
+	if s.cfg.variant == MuxerVariantLowLatency {
+		if err := write(s.curPartMux); err != nil {
+			return nil, err
+		}
+		s.curPartHasData = true
+		s.curSegmentHasData = true
+	} else {
+		if err := write(s.curSegmentMux); err != nil {
+			return nil, err
+		}
+		s.curSegmentHasData = true
+	}
+
+	if s.cfg.variant == MuxerVariantLowLatency && pts-s.curPartStart >= s.cfg.partDuration {
+		s.finishPart(pts)
+		s.startPart(pts)
+	}
+
+	var evicted []*muxerSegment
+
+	if pts-s.segStart >= s.cfg.segmentDuration {
+		if s.cfg.variant == MuxerVariantLowLatency {
+			s.finishPart(pts)
+		}
+		evicted = s.finishSegment(pts)
+		s.startSegment(pts)
+		if s.cfg.variant == MuxerVariantLowLatency {
+			s.startPart(pts)
+		}
+	}
+
+	s.cond.Broadcast()
+
+	return evicted, nil
+}
+
+func (s *muxerSegmenter) segmentExtension() string {
+	if s.cfg.variant == MuxerVariantMPEGTS {
+		return "ts"
+	}
+	return "m4s"
+}
+
+func (s *muxerSegmenter) startSegment(pts time.Duration) {
+	seq := s.nextSeq
+	s.nextSeq++
+	s.segStart = pts
+	s.curSegmentHasData = false
+	s.curPartIndex = 0
+
+	s.curSegment = &muxerSegment{
+		name:          fmt.Sprintf("%s_%d.%s", s.cfg.prefix, seq, s.segmentExtension()),
+		seq:           seq,
+		start:         pts,
+		discontinuity: s.pendingDiscontinuity,
+	}
+	s.pendingDiscontinuity = false
+
+	if s.cfg.variant != MuxerVariantLowLatency {
+		s.curSegmentMux = newSegmentMuxer(s.cfg.variant, s.videoTrack, s.audioTrack)
+	}
+}
+
+// finishSegment finalizes the segment currently being filled and, in LIVE
+// mode, evicts whatever now falls outside the retention window. It returns
+// the evicted segments so the caller can report them via notifyEvicted
+// once s.mutex has been released.
+func (s *muxerSegmenter) finishSegment(pts time.Duration) []*muxerSegment {
+	seg := s.curSegment
+	seg.duration = pts - seg.start
+
+	if s.cfg.variant == MuxerVariantLowLatency {
+		for _, part := range seg.parts {
+			seg.data = append(seg.data, part.data...)
+		}
+	} else if s.curSegmentMux != nil {
+		if data, err := s.curSegmentMux.bytes(pts); err == nil {
+			seg.data = data
+		}
+	}
+
+	if s.cfg.directory != "" {
+		_ = os.WriteFile(filepath.Join(s.cfg.directory, seg.name), seg.data, 0o644)
+	}
+
+	s.segments = append(s.segments, seg)
+	s.totalBytes += int64(len(seg.data))
+
+	// EVENT and VOD playlists only ever grow: segments are retained forever.
+	if s.cfg.playlistType == MuxerPlaylistTypeLive {
+		return s.evictOldSegments(pts)
+	}
+	return nil
+}
+
+// evictOldSegments removes segments from the front of the window until it
+// satisfies SegmentCount, MaxSegmentAge and MaxDiskUsage, returning the
+// evicted segments so the caller can pass them to notifyEvicted, which
+// reports and deletes them, once s.mutex is released.
+func (s *muxerSegmenter) evictOldSegments(now time.Duration) []*muxerSegment {
+	var evicted []*muxerSegment
+
+	for len(s.segments) > 0 && s.windowExceeded(now) {
+		seg := s.segments[0]
+		s.segments = s.segments[1:]
+		s.totalBytes -= int64(len(seg.data))
+		evicted = append(evicted, seg)
+	}
+
+	return evicted
+}
+
+func (s *muxerSegmenter) windowExceeded(now time.Duration) bool {
+	if s.cfg.segmentCount > 0 && len(s.segments) > s.cfg.segmentCount {
+		return true
+	}
+	if s.cfg.maxSegmentAge > 0 && now-s.segments[0].start > s.cfg.maxSegmentAge {
+		return true
+	}
+	if s.cfg.maxDiskUsage > 0 && s.totalBytes > s.cfg.maxDiskUsage {
+		return true
+	}
+	return false
+}
+
+func (s *muxerSegmenter) startPart(pts time.Duration) {
+	s.curPartStart = pts
+	s.curPartHasData = false
+	s.curPartMux = newSegmentMuxer(s.cfg.variant, s.videoTrack, s.audioTrack)
+}
+
+func (s *muxerSegmenter) finishPart(pts time.Duration) {
+	data, err := s.curPartMux.bytes(pts)
+	if err != nil {
+		data = nil
+	}
+
+	part := &muxerPart{
+		name:        fmt.Sprintf("%s_%d_part%d.%s", s.cfg.prefix, s.curSegment.seq, s.curPartIndex, s.segmentExtension()),
+		index:       s.curPartIndex,
+		duration:    pts - s.curPartStart,
+		independent: s.curPartIndex == 0,
+		data:        data,
+	}
+	s.curPartIndex++
+
+	if s.cfg.directory != "" {
+		_ = os.WriteFile(filepath.Join(s.cfg.directory, part.name), part.data, 0o644)
+	}
+
+	s.curSegment.parts = append(s.curSegment.parts, part)
+}
+
+// waitUntilAvailable blocks until segment msn (and, if given, part) has
+// been produced, implementing the blocking playlist reload required by
+// Low-Latency HLS.
+func (s *muxerSegmenter) waitUntilAvailable(ctx context.Context, msn int, part int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for !s.closed && ctx.Err() == nil {
+		if s.curSegment != nil && uint64(msn) <= s.curSegment.seq {
+			if uint64(msn) < s.curSegment.seq || (part >= 0 && part < len(s.curSegment.parts)) {
+				return
+			}
+		}
+		s.cond.Wait()
+	}
+}
+
+func (s *muxerSegmenter) generateMediaPlaylist() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:9\n")
+	b.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(s.cfg.segmentDuration.Seconds()+0.999)))
+
+	switch s.cfg.playlistType {
+	case MuxerPlaylistTypeEvent:
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	case MuxerPlaylistTypeVOD:
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+
+	if len(s.segments) > 0 {
+		b.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", s.segments[0].seq))
+	}
+
+	if s.cfg.variant == MuxerVariantLowLatency {
+		sc := playlist.ServerControl{
+			CanBlockReload: true,
+			PartHoldBack:   s.cfg.partDuration.Seconds() * 3,
+		}
+		b.WriteString(sc.Marshal() + "\n")
+
+		pi := playlist.PartInf{PartTarget: s.cfg.partDuration.Seconds()}
+		b.WriteString(pi.Marshal() + "\n")
+	}
+
+	if s.cfg.variant != MuxerVariantMPEGTS && s.initName != "" {
+		b.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", s.initName))
+	}
+
+	b.WriteString("\n")
+
+	for _, seg := range s.segments {
+		if seg.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		for _, part := range seg.parts {
+			p := playlist.Part{
+				URI:         part.name,
+				Duration:    part.duration.Seconds(),
+				Independent: part.independent,
+			}
+			b.WriteString(p.Marshal() + "\n")
+		}
+		b.WriteString(fmt.Sprintf("#EXTINF:%.5f,\n%s\n", seg.duration.Seconds(), seg.name))
+	}
+
+	if s.cfg.variant == MuxerVariantLowLatency && s.curSegment != nil {
+		hint := playlist.PreloadHint{
+			URI: fmt.Sprintf("%s_%d_part%d.%s", s.cfg.prefix, s.curSegment.seq, s.curPartIndex, s.segmentExtension()),
+		}
+		b.WriteString(hint.Marshal() + "\n")
+	}
+
+	if s.cfg.playlistType == MuxerPlaylistTypeVOD && s.closed {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return b.String()
+}
+
+func (s *muxerSegmenter) segmentOrPartByName(name string) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.initName != "" && name == s.initName {
+		return s.initSegment, true
+	}
+
+	for _, seg := range s.segments {
+		if seg.name == name {
+			return seg.data, true
+		}
+		for _, part := range seg.parts {
+			if part.name == name {
+				return part.data, true
+			}
+		}
+	}
+
+	if s.curSegment != nil {
+		for _, part := range s.curSegment.parts {
+			if part.name == name {
+				return part.data, true
+			}
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,83 @@
+package gohlslib
+
+import "testing"
+
+func TestMuxerSegmenterWindowExceededBySegmentCount(t *testing.T) {
+	s := &muxerSegmenter{
+		cfg: muxerSegmenterConfig{segmentCount: 2},
+		segments: []*muxerSegment{
+			{start: 0},
+			{start: 1},
+			{start: 2},
+		},
+	}
+
+	if !s.windowExceeded(2) {
+		t.Error("windowExceeded() = false, want true when segment count exceeds SegmentCount")
+	}
+}
+
+func TestMuxerSegmenterWindowNotExceeded(t *testing.T) {
+	s := &muxerSegmenter{
+		cfg: muxerSegmenterConfig{segmentCount: 3},
+		segments: []*muxerSegment{
+			{start: 0},
+			{start: 1},
+			{start: 2},
+		},
+	}
+
+	if s.windowExceeded(2) {
+		t.Error("windowExceeded() = true, want false when within SegmentCount")
+	}
+}
+
+func TestMuxerSegmenterWindowExceededByAge(t *testing.T) {
+	s := &muxerSegmenter{
+		cfg: muxerSegmenterConfig{maxSegmentAge: 5},
+		segments: []*muxerSegment{
+			{start: 0},
+		},
+	}
+
+	if !s.windowExceeded(10) {
+		t.Error("windowExceeded() = false, want true when oldest segment exceeds MaxSegmentAge")
+	}
+}
+
+func TestMuxerSegmenterWindowExceededByDiskUsage(t *testing.T) {
+	s := &muxerSegmenter{
+		cfg:        muxerSegmenterConfig{maxDiskUsage: 100},
+		totalBytes: 150,
+		segments: []*muxerSegment{
+			{start: 0},
+		},
+	}
+
+	if !s.windowExceeded(0) {
+		t.Error("windowExceeded() = false, want true when totalBytes exceeds MaxDiskUsage")
+	}
+}
+
+func TestMuxerSegmenterEvictOldSegmentsReturnsEvicted(t *testing.T) {
+	seg0 := &muxerSegment{name: "seg0.ts", seq: 0, start: 0, data: []byte{1, 2, 3}}
+	seg1 := &muxerSegment{name: "seg1.ts", seq: 1, start: 1, data: []byte{4, 5}}
+
+	s := &muxerSegmenter{
+		cfg:        muxerSegmenterConfig{segmentCount: 1},
+		segments:   []*muxerSegment{seg0, seg1},
+		totalBytes: int64(len(seg0.data) + len(seg1.data)),
+	}
+
+	evicted := s.evictOldSegments(1)
+
+	if len(evicted) != 1 || evicted[0] != seg0 {
+		t.Fatalf("evictOldSegments() = %v, want [seg0]", evicted)
+	}
+	if len(s.segments) != 1 || s.segments[0] != seg1 {
+		t.Errorf("remaining segments = %v, want [seg1]", s.segments)
+	}
+	if s.totalBytes != int64(len(seg1.data)) {
+		t.Errorf("totalBytes = %d, want %d", s.totalBytes, len(seg1.data))
+	}
+}
@@ -0,0 +1,7 @@
+// Package codecs contains the codec definitions accepted by gohlslib tracks.
+package codecs
+
+// Codec is implemented by all codecs that can be carried by a Track.
+type Codec interface {
+	isCodec()
+}
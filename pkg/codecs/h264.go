@@ -0,0 +1,13 @@
+package codecs
+
+// H264 is the H264 codec.
+type H264 struct {
+	// SPS is the sequence parameter set, used to build the fMP4 init
+	// segment.
+	SPS []byte
+
+	// PPS is the picture parameter set, used to build the fMP4 init segment.
+	PPS []byte
+}
+
+func (H264) isCodec() {}
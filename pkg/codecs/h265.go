@@ -0,0 +1,16 @@
+package codecs
+
+// H265 is the H265 codec.
+type H265 struct {
+	// VPS is the video parameter set, used to build the fMP4 init segment.
+	VPS []byte
+
+	// SPS is the sequence parameter set, used to build the fMP4 init
+	// segment.
+	SPS []byte
+
+	// PPS is the picture parameter set, used to build the fMP4 init segment.
+	PPS []byte
+}
+
+func (H265) isCodec() {}
@@ -0,0 +1,10 @@
+package codecs
+
+import "github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+
+// MPEG4Audio is the MPEG-4 Audio (AAC) codec.
+type MPEG4Audio struct {
+	Config mpeg4audio.Config
+}
+
+func (MPEG4Audio) isCodec() {}
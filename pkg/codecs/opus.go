@@ -0,0 +1,8 @@
+package codecs
+
+// Opus is the Opus codec.
+type Opus struct {
+	ChannelCount int
+}
+
+func (Opus) isCodec() {}
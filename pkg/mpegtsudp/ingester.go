@@ -0,0 +1,137 @@
+// Package mpegtsudp ingests a MPEG-TS stream carried over UDP, including IP
+// multicast groups and RTP-wrapped payloads (RFC 2250).
+package mpegtsudp
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+)
+
+const defaultReadBufferSize = 2 * 1024 * 1024
+
+// Ingester listens for a MPEG-TS stream over UDP and exposes it as a
+// mediacommon mpegts.Reader, ready to be wired into a gohlslib Muxer
+// exactly like a reader created from a plain net.PacketConn: call
+// Tracks(), register OnDataH26x/OnDataMPEG4Audio, then loop over Read().
+type Ingester struct {
+	// Address is the address to listen on, e.g. "0.0.0.0:5000" for unicast
+	// or "239.1.1.1:5000" for an IP multicast group.
+	Address string
+
+	// ReadBufferSize is the size, in bytes, of the socket read buffer.
+	// Defaults to 2 MiB.
+	ReadBufferSize int
+
+	// MulticastInterface is the name of the network interface used to send
+	// the IGMP join. Required on machines with more than one interface
+	// when Address is a multicast address.
+	MulticastInterface string
+
+	// RTP marks the payload as RTP-encapsulated MPEG-TS (RFC 2250): the
+	// 12-byte RTP header is stripped from every packet before it reaches
+	// the MPEG-TS reader, and gaps in the RTP sequence number are reported
+	// through OnPacketLoss.
+	RTP bool
+
+	// OnPacketLoss, if set, is called with the number of packets a gap in
+	// the RTP sequence number indicates were lost. Unused unless RTP is true.
+	OnPacketLoss func(lost int)
+
+	pc       net.PacketConn
+	seqTrack rtpSequenceTracker
+	leftover []byte
+}
+
+// Start opens the socket, joining the multicast group if Address is a
+// multicast address, and returns a MPEG-TS reader fed by it.
+func (ing *Ingester) Start() (*mpegts.Reader, error) {
+	if ing.ReadBufferSize == 0 {
+		ing.ReadBufferSize = defaultReadBufferSize
+	}
+
+	pc, err := net.ListenPacket("udp", ing.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, err := net.SplitHostPort(ing.Address)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsMulticast() {
+		err = ing.joinMulticast(pc, ip)
+		if err != nil {
+			pc.Close()
+			return nil, err
+		}
+	}
+
+	if udpConn, ok := pc.(*net.UDPConn); ok {
+		_ = udpConn.SetReadBuffer(ing.ReadBufferSize)
+	}
+
+	ing.pc = pc
+
+	return mpegts.NewReader(mpegts.NewBufferedReader(ing))
+}
+
+func (ing *Ingester) joinMulticast(pc net.PacketConn, group net.IP) error {
+	var iface *net.Interface
+	if ing.MulticastInterface != "" {
+		var err error
+		iface, err = net.InterfaceByName(ing.MulticastInterface)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ipv4.NewPacketConn(pc).JoinGroup(iface, &net.UDPAddr{IP: group})
+}
+
+// Read implements io.Reader, so that an Ingester can be passed directly to
+// mpegts.NewBufferedReader: it reads one UDP datagram at a time, strips the
+// RTP header when RTP is enabled, and buffers any bytes the caller didn't
+// have room for so no datagram is ever partially lost.
+func (ing *Ingester) Read(buf []byte) (int, error) {
+	if len(ing.leftover) == 0 {
+		pkt := make([]byte, 65536)
+
+		n, _, err := ing.pc.ReadFrom(pkt)
+		if err != nil {
+			return 0, err
+		}
+		pkt = pkt[:n]
+
+		if ing.RTP {
+			payload, seq, err := parseRTP(pkt)
+			if err != nil {
+				return 0, err
+			}
+
+			if lost := ing.seqTrack.check(seq); lost > 0 && ing.OnPacketLoss != nil {
+				ing.OnPacketLoss(lost)
+			}
+
+			pkt = payload
+		}
+
+		ing.leftover = pkt
+	}
+
+	n := copy(buf, ing.leftover)
+	ing.leftover = ing.leftover[n:]
+	return n, nil
+}
+
+// Close closes the underlying socket.
+func (ing *Ingester) Close() error {
+	return ing.pc.Close()
+}
+
+var errRTPPacketTooShort = fmt.Errorf("RTP packet too short")
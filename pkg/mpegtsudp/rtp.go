@@ -0,0 +1,55 @@
+package mpegtsudp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	rtpHeaderSize      = 12
+	rtpPayloadTypeMP2T = 33 // RFC 3551
+)
+
+// parseRTP validates a RTP packet, checks that its payload type indicates
+// MP2T (RFC 2250) and returns the MPEG-TS payload and sequence number.
+func parseRTP(pkt []byte) (payload []byte, seq uint16, err error) {
+	if len(pkt) < rtpHeaderSize {
+		return nil, 0, errRTPPacketTooShort
+	}
+
+	payloadType := pkt[1] & 0x7F
+	if payloadType != rtpPayloadTypeMP2T {
+		return nil, 0, fmt.Errorf("unsupported RTP payload type: %d", payloadType)
+	}
+
+	seq = binary.BigEndian.Uint16(pkt[2:4])
+
+	return pkt[rtpHeaderSize:], seq, nil
+}
+
+// rtpSequenceTracker detects gaps in a RTP sequence number stream.
+type rtpSequenceTracker struct {
+	last uint16
+	have bool
+}
+
+// check records seq and returns the number of packets presumed lost since
+// the previous call, accounting for 16-bit wraparound.
+func (t *rtpSequenceTracker) check(seq uint16) int {
+	if !t.have {
+		t.have = true
+		t.last = seq
+		return 0
+	}
+
+	expected := t.last + 1
+	// seq-expected must stay a 16-bit wraparound-aware difference, but widen
+	// it through a signed 16-bit value before converting to int: doing the
+	// subtraction directly in uint16 would make a reordered/duplicate/late
+	// packet (seq < expected) wrap around to a huge positive value instead
+	// of the small negative one it actually represents.
+	lost := int(int16(seq - expected))
+	t.last = seq
+
+	return lost
+}
@@ -0,0 +1,88 @@
+package mpegtsudp
+
+import (
+	"testing"
+)
+
+func rtpPacket(seq uint16, payload []byte) []byte {
+	pkt := make([]byte, rtpHeaderSize+len(payload))
+	pkt[0] = 0x80
+	pkt[1] = rtpPayloadTypeMP2T
+	pkt[2] = byte(seq >> 8)
+	pkt[3] = byte(seq)
+	copy(pkt[rtpHeaderSize:], payload)
+	return pkt
+}
+
+func TestParseRTP(t *testing.T) {
+	payload := []byte{1, 2, 3, 4}
+	pkt := rtpPacket(1234, payload)
+
+	gotPayload, gotSeq, err := parseRTP(pkt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotSeq != 1234 {
+		t.Errorf("seq = %d, want 1234", gotSeq)
+	}
+	if string(gotPayload) != string(payload) {
+		t.Errorf("payload = %v, want %v", gotPayload, payload)
+	}
+}
+
+func TestParseRTPTooShort(t *testing.T) {
+	_, _, err := parseRTP(make([]byte, rtpHeaderSize-1))
+	if err != errRTPPacketTooShort {
+		t.Fatalf("err = %v, want errRTPPacketTooShort", err)
+	}
+}
+
+func TestParseRTPWrongPayloadType(t *testing.T) {
+	pkt := rtpPacket(0, []byte{0})
+	pkt[1] = 96 // some dynamic payload type, not MP2T
+
+	_, _, err := parseRTP(pkt)
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
+
+func TestRTPSequenceTrackerNoLoss(t *testing.T) {
+	var tr rtpSequenceTracker
+
+	if lost := tr.check(10); lost != 0 {
+		t.Errorf("first check: lost = %d, want 0", lost)
+	}
+	if lost := tr.check(11); lost != 0 {
+		t.Errorf("consecutive check: lost = %d, want 0", lost)
+	}
+}
+
+func TestRTPSequenceTrackerGap(t *testing.T) {
+	var tr rtpSequenceTracker
+
+	tr.check(10)
+	if lost := tr.check(15); lost != 4 {
+		t.Errorf("lost = %d, want 4", lost)
+	}
+}
+
+func TestRTPSequenceTrackerWraparound(t *testing.T) {
+	var tr rtpSequenceTracker
+
+	tr.check(65534)
+	if lost := tr.check(1); lost != 2 {
+		t.Errorf("lost = %d, want 2", lost)
+	}
+}
+
+func TestRTPSequenceTrackerReorder(t *testing.T) {
+	var tr rtpSequenceTracker
+
+	tr.check(10)
+	// an earlier-than-expected sequence number should be reported as a
+	// negative loss, not misinterpreted as a huge gap.
+	if lost := tr.check(9); lost >= 0 {
+		t.Errorf("lost = %d, want a negative value", lost)
+	}
+}
@@ -0,0 +1,64 @@
+package playlist
+
+import "strings"
+
+// MasterVariant is a single #EXT-X-STREAM-INF entry of a master playlist.
+type MasterVariant struct {
+	Bandwidth int
+	Codecs    string
+	URI       string
+}
+
+// MasterPlaylist is a parsed HLS master playlist.
+type MasterPlaylist struct {
+	Variants []MasterVariant
+}
+
+// UnmarshalMaster parses a master playlist.
+func UnmarshalMaster(raw []byte) (*MasterPlaylist, error) {
+	lines := strings.Split(string(raw), "\n")
+	pl := &MasterPlaylist{}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if !strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			continue
+		}
+
+		variant := MasterVariant{}
+		attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+
+		if v, ok := attrs["BANDWIDTH"]; ok {
+			variant.Bandwidth = parseInt(v)
+		}
+		variant.Codecs = strings.Trim(attrs["CODECS"], "\"")
+
+		// the URI is on the next non-empty, non-comment line
+		for j := i + 1; j < len(lines); j++ {
+			uriLine := strings.TrimSpace(lines[j])
+			if uriLine == "" || strings.HasPrefix(uriLine, "#") {
+				continue
+			}
+			variant.URI = uriLine
+			i = j
+			break
+		}
+
+		pl.Variants = append(pl.Variants, variant)
+	}
+
+	return pl, nil
+}
+
+// BestVariant returns the variant with the highest bandwidth, used as the
+// default selection when the caller doesn't request a specific rendition.
+func (p *MasterPlaylist) BestVariant() *MasterVariant {
+	var best *MasterVariant
+	for i, v := range p.Variants {
+		if best == nil || v.Bandwidth > best.Bandwidth {
+			best = &p.Variants[i]
+		}
+	}
+	return best
+}
@@ -0,0 +1,51 @@
+package playlist
+
+import "testing"
+
+const testMasterPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=800000,CODECS="avc1.64001f,mp4a.40.2"
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2800000,CODECS="avc1.64001f,mp4a.40.2"
+high/index.m3u8
+`
+
+func TestUnmarshalMaster(t *testing.T) {
+	pl, err := UnmarshalMaster([]byte(testMasterPlaylist))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pl.Variants) != 2 {
+		t.Fatalf("got %d variants, want 2", len(pl.Variants))
+	}
+
+	if pl.Variants[0].Bandwidth != 800000 || pl.Variants[0].URI != "low/index.m3u8" {
+		t.Errorf("variant 0 = %+v", pl.Variants[0])
+	}
+	if pl.Variants[1].Bandwidth != 2800000 || pl.Variants[1].URI != "high/index.m3u8" {
+		t.Errorf("variant 1 = %+v", pl.Variants[1])
+	}
+	if pl.Variants[0].Codecs != "avc1.64001f,mp4a.40.2" {
+		t.Errorf("variant 0 codecs = %q", pl.Variants[0].Codecs)
+	}
+}
+
+func TestMasterPlaylistBestVariant(t *testing.T) {
+	pl, err := UnmarshalMaster([]byte(testMasterPlaylist))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	best := pl.BestVariant()
+	if best == nil || best.URI != "high/index.m3u8" {
+		t.Errorf("BestVariant() = %+v, want the 2800000 bps variant", best)
+	}
+}
+
+func TestMasterPlaylistBestVariantEmpty(t *testing.T) {
+	pl := &MasterPlaylist{}
+	if best := pl.BestVariant(); best != nil {
+		t.Errorf("BestVariant() on empty playlist = %+v, want nil", best)
+	}
+}
@@ -0,0 +1,66 @@
+package playlist
+
+import "strings"
+
+// MediaSegment is a single segment entry of a media playlist.
+type MediaSegment struct {
+	Seq           uint64
+	Duration      float64
+	URI           string
+	Discontinuity bool
+}
+
+// MediaPlaylist is a parsed HLS media playlist.
+type MediaPlaylist struct {
+	TargetDuration int
+	MediaSequence  uint64
+	Segments       []MediaSegment
+	Endlist        bool
+}
+
+// UnmarshalMedia parses a media playlist.
+func UnmarshalMedia(raw []byte) (*MediaPlaylist, error) {
+	lines := strings.Split(string(raw), "\n")
+	pl := &MediaPlaylist{}
+
+	seq := uint64(0)
+	pendingDuration := 0.0
+	pendingDiscontinuity := false
+
+	for _, rawLine := range lines {
+		line := strings.TrimSpace(rawLine)
+
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			pl.MediaSequence = uint64(parseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:")))
+			seq = pl.MediaSequence
+
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			pl.TargetDuration = parseInt(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"))
+
+		case strings.HasPrefix(line, "#EXT-X-DISCONTINUITY"):
+			pendingDiscontinuity = true
+
+		case strings.HasPrefix(line, "#EXT-X-ENDLIST"):
+			pl.Endlist = true
+
+		case strings.HasPrefix(line, "#EXTINF:"):
+			pendingDuration = parseFloat(strings.TrimSuffix(strings.TrimPrefix(line, "#EXTINF:"), ","))
+
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+
+		default:
+			pl.Segments = append(pl.Segments, MediaSegment{
+				Seq:           seq,
+				Duration:      pendingDuration,
+				URI:           line,
+				Discontinuity: pendingDiscontinuity,
+			})
+			seq++
+			pendingDiscontinuity = false
+		}
+	}
+
+	return pl, nil
+}
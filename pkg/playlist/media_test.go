@@ -0,0 +1,64 @@
+package playlist
+
+import "testing"
+
+const testMediaPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:10
+#EXTINF:6.000,
+seg10.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+seg11.ts
+#EXT-X-ENDLIST
+`
+
+func TestUnmarshalMedia(t *testing.T) {
+	pl, err := UnmarshalMedia([]byte(testMediaPlaylist))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if pl.TargetDuration != 6 {
+		t.Errorf("TargetDuration = %d, want 6", pl.TargetDuration)
+	}
+	if pl.MediaSequence != 10 {
+		t.Errorf("MediaSequence = %d, want 10", pl.MediaSequence)
+	}
+	if !pl.Endlist {
+		t.Error("Endlist = false, want true")
+	}
+
+	if len(pl.Segments) != 2 {
+		t.Fatalf("got %d segments, want 2", len(pl.Segments))
+	}
+
+	if pl.Segments[0].Seq != 10 || pl.Segments[0].URI != "seg10.ts" || pl.Segments[0].Discontinuity {
+		t.Errorf("segment 0 = %+v", pl.Segments[0])
+	}
+	if pl.Segments[1].Seq != 11 || pl.Segments[1].URI != "seg11.ts" || !pl.Segments[1].Discontinuity {
+		t.Errorf("segment 1 = %+v", pl.Segments[1])
+	}
+	if pl.Segments[0].Duration != 6.0 {
+		t.Errorf("segment 0 duration = %v, want 6.0", pl.Segments[0].Duration)
+	}
+}
+
+func TestUnmarshalMediaNoEndlist(t *testing.T) {
+	raw := `#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+seg0.ts
+`
+	pl, err := UnmarshalMedia([]byte(raw))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pl.Endlist {
+		t.Error("Endlist = true, want false")
+	}
+	if len(pl.Segments) != 1 {
+		t.Fatalf("got %d segments, want 1", len(pl.Segments))
+	}
+}
@@ -0,0 +1,63 @@
+// Package playlist contains helpers for generating and parsing HLS
+// media/master playlist tags.
+package playlist
+
+import "fmt"
+
+// ServerControl renders an EXT-X-SERVER-CONTROL tag.
+type ServerControl struct {
+	CanBlockReload bool
+	PartHoldBack   float64
+}
+
+// Marshal returns the tag as a playlist line.
+func (s ServerControl) Marshal() string {
+	s2 := "#EXT-X-SERVER-CONTROL:"
+	if s.CanBlockReload {
+		s2 += "CAN-BLOCK-RELOAD=YES,"
+	}
+	s2 += fmt.Sprintf("PART-HOLD-BACK=%.3f", s.PartHoldBack)
+	return s2
+}
+
+// PartInf renders an EXT-X-PART-INF tag.
+type PartInf struct {
+	PartTarget float64
+}
+
+// Marshal returns the tag as a playlist line.
+func (p PartInf) Marshal() string {
+	return fmt.Sprintf("#EXT-X-PART-INF:PART-TARGET=%.5f", p.PartTarget)
+}
+
+// Part renders an EXT-X-PART tag, one per partial segment.
+type Part struct {
+	URI         string
+	Duration    float64
+	Independent bool
+	Gap         bool
+}
+
+// Marshal returns the tag as a playlist line.
+func (p Part) Marshal() string {
+	s := fmt.Sprintf("#EXT-X-PART:DURATION=%.5f,URI=\"%s\"", p.Duration, p.URI)
+	if p.Independent {
+		s += ",INDEPENDENT=YES"
+	}
+	if p.Gap {
+		s += ",GAP=YES"
+	}
+	return s
+}
+
+// PreloadHint renders an EXT-X-PRELOAD-HINT tag, announcing the part that is
+// currently being filled so that blocking clients can start downloading it
+// before it is complete.
+type PreloadHint struct {
+	URI string
+}
+
+// Marshal returns the tag as a playlist line.
+func (p PreloadHint) Marshal() string {
+	return fmt.Sprintf("#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"%s\"", p.URI)
+}
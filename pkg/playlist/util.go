@@ -0,0 +1,51 @@
+package playlist
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseAttributes splits a comma-separated ATTR=VALUE list, honoring commas
+// that appear inside quoted values (e.g. CODECS="avc1.64001f,mp4a.40.2").
+func parseAttributes(raw string) map[string]string {
+	attrs := map[string]string{}
+
+	var cur strings.Builder
+	inQuotes := false
+	var parts []string
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			cur.WriteRune(r)
+		case r == ',' && !inQuotes:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		parts = append(parts, cur.String())
+	}
+
+	for _, part := range parts {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			attrs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+
+	return attrs
+}
+
+func parseInt(s string) int {
+	v, _ := strconv.Atoi(strings.TrimSpace(s))
+	return v
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
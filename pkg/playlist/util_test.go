@@ -0,0 +1,55 @@
+package playlist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAttributes(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		raw  string
+		want map[string]string
+	}{
+		{
+			name: "simple",
+			raw:  "BANDWIDTH=1280000,RESOLUTION=640x360",
+			want: map[string]string{"BANDWIDTH": "1280000", "RESOLUTION": "640x360"},
+		},
+		{
+			name: "quoted value with comma",
+			raw:  `BANDWIDTH=1280000,CODECS="avc1.64001f,mp4a.40.2"`,
+			want: map[string]string{"BANDWIDTH": "1280000", "CODECS": `"avc1.64001f,mp4a.40.2"`},
+		},
+		{
+			name: "spaces around values",
+			raw:  "BANDWIDTH=1280000, CODECS=\"avc1.64001f\"",
+			want: map[string]string{"BANDWIDTH": "1280000", "CODECS": `"avc1.64001f"`},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			got := parseAttributes(ca.raw)
+			if !reflect.DeepEqual(got, ca.want) {
+				t.Errorf("parseAttributes(%q) = %v, want %v", ca.raw, got, ca.want)
+			}
+		})
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	if v := parseInt(" 42 "); v != 42 {
+		t.Errorf("parseInt = %d, want 42", v)
+	}
+	if v := parseInt("not a number"); v != 0 {
+		t.Errorf("parseInt = %d, want 0", v)
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	if v := parseFloat(" 6.006 "); v != 6.006 {
+		t.Errorf("parseFloat = %v, want 6.006", v)
+	}
+	if v := parseFloat("not a number"); v != 0 {
+		t.Errorf("parseFloat = %v, want 0", v)
+	}
+}
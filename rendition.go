@@ -0,0 +1,243 @@
+package gohlslib
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mch264 "github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	mch265 "github.com/bluenviron/mediacommon/pkg/codecs/h265"
+)
+
+// Rendition is a single entry of an ABR ladder managed by a Muxer.
+type Rendition struct {
+	// Prefix is prepended to the name of this rendition's media playlist
+	// and segments, and used as the media playlist filename (Prefix + ".m3u8").
+	Prefix string
+
+	// VideoTrack is the video track of this rendition, if any.
+	VideoTrack *Track
+
+	// AudioTrack is the audio track muxed into this rendition, if any.
+	AudioTrack *Track
+
+	// AudioGroup, if set, is advertised as this rendition's #EXT-X-STREAM-INF
+	// AUDIO attribute, pointing playback at the AlternateAudio entry whose
+	// GroupID matches. Left out of the playlist when empty, in which case
+	// the rendition is not paired with any AlternateAudio group.
+	AudioGroup string
+
+	// Bandwidth is the peak bitrate of this rendition, in bits per second,
+	// advertised through the master playlist's BANDWIDTH attribute.
+	Bandwidth int
+
+	// FrameRate is advertised through the master playlist's FRAME-RATE
+	// attribute. Left out of the playlist when zero.
+	FrameRate float64
+
+	muxer     *Muxer
+	segmenter *muxerSegmenter
+
+	mutex      sync.Mutex
+	codecs     string
+	resolution string
+}
+
+// profileCompatibilityByte rebuilds the middle byte of the avc1.PPCCLL
+// CODECS value (the six constraint_set flags, packed as defined by the
+// H264 spec) from the actual SPS instead of assuming it is zero.
+func profileCompatibilityByte(sps mch264.SPS) byte {
+	var b byte
+	if sps.ConstraintSet0Flag {
+		b |= 1 << 7
+	}
+	if sps.ConstraintSet1Flag {
+		b |= 1 << 6
+	}
+	if sps.ConstraintSet2Flag {
+		b |= 1 << 5
+	}
+	if sps.ConstraintSet3Flag {
+		b |= 1 << 4
+	}
+	if sps.ConstraintSet4Flag {
+		b |= 1 << 3
+	}
+	if sps.ConstraintSet5Flag {
+		b |= 1 << 2
+	}
+	return b
+}
+
+// h265CodecString builds the hvc1.* CODECS value defined by ISO/IEC
+// 14496-15 Annex E.3 from the actual profile/tier/level/constraint fields
+// of an H265 SPS.
+func h265CodecString(sps mch265.SPS) string {
+	ptl := sps.ProfileTierLevel
+
+	var compat uint32
+	for i, set := range ptl.GeneralProfileCompatibilityFlag {
+		if set {
+			compat |= 1 << uint(i)
+		}
+	}
+
+	tier := "L"
+	if ptl.GeneralTierFlag != 0 {
+		tier = "H"
+	}
+
+	constraintFlags := []bool{
+		ptl.GeneralProgressiveSourceFlag,
+		ptl.GeneralInterlacedSourceFlag,
+		ptl.GeneralNonPackedConstraintFlag,
+		ptl.GeneralFrameOnlyConstraintFlag,
+		ptl.GeneralMax12bitConstraintFlag,
+		ptl.GeneralMax10bitConstraintFlag,
+		ptl.GeneralMax8bitConstraintFlag,
+		ptl.GeneralMax422ChromeConstraintFlag,
+		ptl.GeneralMax420ChromaConstraintFlag,
+		ptl.GeneralMaxMonochromeConstraintFlag,
+		ptl.GeneralIntraConstraintFlag,
+		ptl.GeneralOnePictureOnlyConstraintFlag,
+		ptl.GeneralLowerBitRateConstraintFlag,
+		ptl.GeneralMax14BitConstraintFlag,
+	}
+
+	var constraintBytes [6]byte
+	for i, set := range constraintFlags {
+		if set {
+			constraintBytes[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+
+	profilePrefix := ""
+	if ptl.GeneralProfileSpace > 0 {
+		profilePrefix = string(rune('A' - 1 + int(ptl.GeneralProfileSpace)))
+	}
+
+	constraintParts := make([]string, len(constraintBytes))
+	for i, b := range constraintBytes {
+		constraintParts[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return fmt.Sprintf("hvc1.%s%d.%X.%s%d.%s",
+		profilePrefix, ptl.GeneralProfileIdc, compat, tier, ptl.GeneralLevelIdc, strings.Join(constraintParts, "."))
+}
+
+// codecsForVideoParams derives the master playlist CODECS value for the
+// parameters detectVideoParams extracted, for either H264 or H265.
+func codecsForVideoParams(p videoParams) string {
+	if p.isH265 {
+		var sps mch265.SPS
+		if err := sps.Unmarshal(p.sps); err != nil {
+			return ""
+		}
+		return h265CodecString(sps)
+	}
+
+	var sps mch264.SPS
+	if err := sps.Unmarshal(p.sps); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("avc1.%02x%02x%02x", sps.ProfileIdc, profileCompatibilityByte(sps), sps.LevelIdc)
+}
+
+func (re *Rendition) start(m *Muxer) {
+	re.muxer = m
+	cfg := m.segmenterConfig()
+	cfg.prefix = re.Prefix
+	cfg.videoTrack = re.VideoTrack
+	cfg.audioTrack = re.AudioTrack
+	re.segmenter = newMuxerSegmenter(cfg)
+}
+
+func (re *Rendition) playlistName() string {
+	return re.Prefix + ".m3u8"
+}
+
+// WriteH26x writes an H264 or H265 access unit to this rendition.
+func (re *Rendition) WriteH26x(ntp time.Time, pts time.Duration, au [][]byte) error {
+	re.detectVideoParams(au)
+	return re.segmenter.writeH26x(ntp, pts, au)
+}
+
+// WriteMPEG4Audio writes a group of MPEG-4 Audio access units to this rendition.
+func (re *Rendition) WriteMPEG4Audio(ntp time.Time, pts time.Duration, aus [][]byte) error {
+	return re.segmenter.writeMPEG4Audio(ntp, pts, aus)
+}
+
+// detectVideoParams extracts CODECS and RESOLUTION from the stream's SPS,
+// the first time one is found, then asks the Muxer to regenerate the
+// master playlist so that it reflects the real stream parameters instead
+// of a user-supplied guess. It shares the SPS/PPS/VPS scanning that
+// AutoDetectTracks uses (autodetect.go's detectVideoParams), so H265
+// Renditions get a CODECS attribute too, instead of only H264 ones.
+func (re *Rendition) detectVideoParams(au [][]byte) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	if re.codecs != "" {
+		return
+	}
+
+	params, ok := detectVideoParams(au)
+	if !ok {
+		return
+	}
+
+	re.codecs = codecsForVideoParams(params)
+	re.resolution = fmt.Sprintf("%dx%d", params.width, params.height)
+	if re.FrameRate == 0 {
+		re.FrameRate = params.frameRate
+	}
+
+	go re.muxer.regenerateMasterManifest()
+}
+
+// AlternateAudio is an audio-only rendition advertised through an
+// #EXT-X-MEDIA:TYPE=AUDIO tag in the master playlist.
+type AlternateAudio struct {
+	// GroupID is the audio group this rendition belongs to. Video renditions
+	// that should use it reference the same value through their
+	// #EXT-X-STREAM-INF AUDIO attribute.
+	GroupID string
+
+	// Name is the human-readable name of the rendition (NAME attribute).
+	Name string
+
+	// Language is the BCP 47 language tag of the rendition, if any.
+	Language string
+
+	// Default marks the rendition as the default of its group.
+	Default bool
+
+	// Prefix is prepended to the name of this rendition's media playlist
+	// and segments, and used as the media playlist filename (Prefix + ".m3u8").
+	Prefix string
+
+	// AudioTrack is the audio track of this rendition.
+	AudioTrack *Track
+
+	muxer     *Muxer
+	segmenter *muxerSegmenter
+}
+
+func (aa *AlternateAudio) start(m *Muxer) {
+	aa.muxer = m
+	cfg := m.segmenterConfig()
+	cfg.prefix = aa.Prefix
+	cfg.videoTrack = nil
+	cfg.audioTrack = aa.AudioTrack
+	aa.segmenter = newMuxerSegmenter(cfg)
+}
+
+func (aa *AlternateAudio) playlistName() string {
+	return aa.Prefix + ".m3u8"
+}
+
+// WriteMPEG4Audio writes a group of MPEG-4 Audio access units to this rendition.
+func (aa *AlternateAudio) WriteMPEG4Audio(ntp time.Time, pts time.Duration, aus [][]byte) error {
+	return aa.segmenter.writeMPEG4Audio(ntp, pts, aus)
+}
@@ -0,0 +1,9 @@
+package gohlslib
+
+import "github.com/bluenviron/gohlslib/pkg/codecs"
+
+// Track is a track offered by a Muxer or read by a Client.
+type Track struct {
+	// Codec used in the track.
+	Codec codecs.Codec
+}
@@ -0,0 +1,14 @@
+package gohlslib
+
+import "bytes"
+
+// bytesReadSeeker adapts a byte slice to io.ReadSeeker so that segments and
+// parts kept in memory can be served with http.ServeContent, which in turn
+// provides Range request (byte-range) support for free.
+type bytesReadSeeker struct {
+	*bytes.Reader
+}
+
+func newBytesReadSeeker(b []byte) *bytesReadSeeker {
+	return &bytesReadSeeker{Reader: bytes.NewReader(b)}
+}
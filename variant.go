@@ -0,0 +1,35 @@
+package gohlslib
+
+// MuxerVariant is the output variant of a Muxer.
+type MuxerVariant int
+
+const (
+	// MuxerVariantMPEGTS produces MPEG-TS segments, compatible with every HLS client.
+	MuxerVariantMPEGTS MuxerVariant = iota
+
+	// MuxerVariantFMP4 produces fMP4 segments, compatible with iOS 10+ and most modern players.
+	MuxerVariantFMP4
+
+	// MuxerVariantLowLatency produces fMP4 segments made of CMAF parts and
+	// advertises them through EXT-X-PART / EXT-X-PRELOAD-HINT, enabling
+	// Low-Latency HLS.
+	MuxerVariantLowLatency
+)
+
+// MuxerPlaylistType selects the retention policy of a Muxer's media playlist.
+type MuxerPlaylistType int
+
+const (
+	// MuxerPlaylistTypeLive is a sliding-window playlist: segments older
+	// than the retention window (SegmentCount / MaxSegmentAge / MaxDiskUsage)
+	// are deleted as new ones are produced. This is the default.
+	MuxerPlaylistTypeLive MuxerPlaylistType = iota
+
+	// MuxerPlaylistTypeEvent advertises #EXT-X-PLAYLIST-TYPE:EVENT and
+	// never deletes segments: the playlist only ever grows.
+	MuxerPlaylistTypeEvent
+
+	// MuxerPlaylistTypeVOD advertises #EXT-X-PLAYLIST-TYPE:VOD, never
+	// deletes segments, and appends #EXT-X-ENDLIST once Close is called.
+	MuxerPlaylistTypeVOD
+)